@@ -0,0 +1,89 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/lib"
+)
+
+// step is one rate/duration pair of a (possibly stepped) load profile.
+type step struct {
+	rate     int
+	duration time.Duration
+}
+
+// label is the method and URL of one dispatched target, queued by
+// labeledTargeter and popped back out as each of its results arrives.
+type label struct {
+	method, url string
+}
+
+// labeledTargeter wraps tr so the method and URL of each dispatched
+// target can be recovered as its result comes back: vegeta.Result
+// carries neither, and vegeta.Attacker.Attack calls tr internally, so
+// this is the only place to capture them. vegeta calls tr exactly once
+// per result it emits, so queueing each call's labels and popping one
+// per result keeps the two in step across concurrent workers.
+func labeledTargeter(tr vegeta.Targeter) (vegeta.Targeter, func() (method, url string)) {
+	labels := make(chan label, 4096)
+
+	wrapped := func(tgt *vegeta.Target) error {
+		err := tr(tgt)
+		labels <- label{tgt.Method, tgt.URL}
+		return err
+	}
+	return wrapped, func() (string, string) {
+		l := <-labels
+		return l.method, l.url
+	}
+}
+
+// runStep fires a single rate step of the attack and returns the metrics
+// collected for it. Results are streamed to a results-<rate>-<unix>.json
+// file in resultsDir, if set. The attack is cut short if stop fires.
+func runStep(attacker *vegeta.Attacker, targeter vegeta.Targeter, s step, resultsDir string, stop chan os.Signal) *vegeta.Metrics {
+	metrics := &vegeta.Metrics{}
+
+	out := &output{}
+	defer out.close()
+	if resultsDir != "" {
+		if err := out.rotate(resultsDir, s.rate); err != nil {
+			fmt.Fprintln(os.Stderr, "Error opening results file:", err)
+			os.Exit(3)
+		}
+	}
+
+	wrapped, labels := labeledTargeter(targeter)
+	pacer := vegeta.Rate{Freq: s.rate, Per: time.Second}
+	for res := range attacker.Attack(wrapped, pacer, s.duration, fmt.Sprintf("rate-%d", s.rate)) {
+		metrics.Add(res)
+		method, url := labels()
+		recordResult(res, method, url)
+		if out.encoder != nil {
+			out.encoder.Encode(res)
+		}
+		if len(stop) > 0 {
+			break
+		}
+	}
+	metrics.Close()
+	return metrics
+}
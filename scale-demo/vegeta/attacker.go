@@ -0,0 +1,136 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	vegeta "github.com/tsenart/vegeta/lib"
+	h2 "golang.org/x/net/http2"
+)
+
+var (
+	http2       = flag.Bool("http2", true, "Use HTTP/2 when the scheme and server support it")
+	h2c         = flag.Bool("h2c", false, "Use HTTP/2 over cleartext TCP, as spoken by many in-cluster gRPC services")
+	insecure    = flag.Bool("insecure", false, "Skip TLS certificate verification")
+	cert        = flag.String("cert", "", "A client TLS certificate file for mTLS")
+	key         = flag.String("key", "", "The private key that matches -cert")
+	rootCerts   = flag.String("root-certs", "", "A comma separated list of PEM CA bundle files to trust, in addition to the system roots")
+	connections = flag.Int("connections", 10000, "The maximum number of idle open connections per target host")
+	keepalive   = flag.Bool("keepalive", true, "Reuse TCP connections between requests")
+)
+
+// buildAttacker assembles a vegeta.Attacker from -workers and the
+// HTTP/2, h2c and TLS flags above.
+func buildAttacker() (*vegeta.Attacker, error) {
+	opts := []func(*vegeta.Attacker){
+		vegeta.Workers(uint64(*workers)),
+		vegeta.HTTP2(*http2),
+		vegeta.H2C(*h2c),
+		vegeta.Connections(*connections),
+		vegeta.KeepAlive(*keepalive),
+	}
+
+	tlsConfig, err := buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts = append(opts, vegeta.TLSConfig(tlsConfig))
+	}
+
+	return vegeta.NewAttacker(opts...), nil
+}
+
+// buildHTTPClient builds the *http.Client used by -max-workers open-model
+// mode, mirroring buildAttacker's -http2/-h2c/-connections/-keepalive/TLS
+// options so switching between the two dispatch modes doesn't also
+// silently switch which services are reachable. Like vegeta's own H2C
+// attacker option, -h2c replaces the transport outright, so -connections
+// and -keepalive only apply to the non-h2c case below.
+func buildHTTPClient() (*http.Client, error) {
+	if *h2c {
+		return &http.Client{Transport: &h2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(_ context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}}, nil
+	}
+
+	tlsConfig, err := buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		tlsConfig = vegeta.DefaultTLSConfig.Clone()
+	}
+
+	transport := &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConnsPerHost: *connections,
+		DisableKeepAlives:   !*keepalive,
+	}
+	if *http2 {
+		if err := h2.ConfigureTransport(transport); err != nil {
+			return nil, fmt.Errorf("configuring HTTP/2 transport: %v", err)
+		}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// buildTLSConfig returns nil if none of -insecure, -cert or -root-certs
+// were set, so the attacker keeps using vegeta's own TLS defaults.
+func buildTLSConfig() (*tls.Config, error) {
+	if !*insecure && *cert == "" && *rootCerts == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: *insecure}
+
+	if *cert != "" {
+		pair, err := tls.LoadX509KeyPair(*cert, *key)
+		if err != nil {
+			return nil, fmt.Errorf("loading -cert/-key: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+
+	if *rootCerts != "" {
+		pool := x509.NewCertPool()
+		for _, f := range strings.Split(*rootCerts, ",") {
+			pem, err := os.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("reading -root-certs file %q: %v", f, err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in -root-certs file %q", f)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+func TestBuildTLSConfigNoFlags(t *testing.T) {
+	*insecure, *cert, *rootCerts = false, "", ""
+
+	cfg, err := buildTLSConfig()
+	if err != nil {
+		t.Fatalf("buildTLSConfig() error = %v", err)
+	}
+	if cfg != nil {
+		t.Errorf("buildTLSConfig() = %v, want nil when no TLS flags are set", cfg)
+	}
+}
+
+func TestBuildTLSConfigInsecure(t *testing.T) {
+	*insecure, *cert, *rootCerts = true, "", ""
+	defer func() { *insecure = false }()
+
+	cfg, err := buildTLSConfig()
+	if err != nil {
+		t.Fatalf("buildTLSConfig() error = %v", err)
+	}
+	if cfg == nil || !cfg.InsecureSkipVerify {
+		t.Errorf("buildTLSConfig() = %+v, want InsecureSkipVerify = true", cfg)
+	}
+}
+
+func TestBuildTLSConfigMissingCertFile(t *testing.T) {
+	*insecure, *cert, *key, *rootCerts = false, "/nonexistent/cert.pem", "/nonexistent/key.pem", ""
+	defer func() { *cert, *key = "", "" }()
+
+	if _, err := buildTLSConfig(); err == nil {
+		t.Error("buildTLSConfig() with a missing -cert file, want error")
+	}
+}
+
+func TestBuildTLSConfigMissingRootCertsFile(t *testing.T) {
+	*insecure, *cert, *rootCerts = false, "", "/nonexistent/ca.pem"
+	defer func() { *rootCerts = "" }()
+
+	if _, err := buildTLSConfig(); err == nil {
+		t.Error("buildTLSConfig() with a missing -root-certs file, want error")
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/lib"
+)
+
+// maxWorkers, when set, switches the attack from vegeta's closed model
+// (where a slow target naturally throttles the sender) to an open model:
+// requests are dispatched on a fixed schedule regardless of how long the
+// previous ones take, which is what actually surfaces coordinated
+// omission in the tail latencies.
+var maxWorkers = flag.Int("max-workers", 0, "If set, dispatch requests on a fixed open-model schedule using this many worker goroutines, correcting for coordinated omission. 0 uses vegeta's normal closed-model attacker")
+
+// comResult pairs the corrected result (latency measured from the
+// scheduled dispatch time) with the raw one (latency measured from the
+// actual send time), so both can be reported. raw is nil for requests
+// that never got a worker, i.e. the synthetic "late" results. method and
+// url are carried alongside rather than read off *vegeta.Result, which
+// has neither.
+type comResult struct {
+	corrected   *vegeta.Result
+	raw         *vegeta.Result
+	method, url string
+}
+
+// runOpenModelStep runs one rate step of the attack in open-model mode
+// and returns its coordinated-omission-corrected and raw metrics.
+func runOpenModelStep(client *http.Client, targeter vegeta.Targeter, s step, resultsDir string, stop chan os.Signal) (corrected, raw *vegeta.Metrics) {
+	corrected, raw = &vegeta.Metrics{}, &vegeta.Metrics{}
+	if s.rate <= 0 {
+		fmt.Fprintln(os.Stderr, "-max-workers requires a positive -rate/-rates step")
+		corrected.Close()
+		raw.Close()
+		return corrected, raw
+	}
+
+	out := &output{}
+	defer out.close()
+	if resultsDir != "" {
+		if err := out.rotate(resultsDir, s.rate); err != nil {
+			return corrected, raw
+		}
+	}
+
+	jobs := make(chan time.Time, *maxWorkers)
+	results := make(chan comResult, *maxWorkers)
+
+	var workers sync.WaitGroup
+	for i := 0; i < *maxWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for scheduled := range jobs {
+				results <- fire(client, targeter, scheduled)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+
+		interval := time.Second / time.Duration(s.rate)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		deadline := time.Now().Add(s.duration)
+		for scheduled := range ticker.C {
+			if scheduled.After(deadline) || len(stop) > 0 {
+				return
+			}
+			select {
+			case jobs <- scheduled:
+			default:
+				// Every worker is still busy with an earlier request: the
+				// target is behind schedule. Record it as late rather
+				// than silently delaying the dispatch, which is exactly
+				// the coordinated omission this mode corrects for.
+				results <- comResult{corrected: lateResult(scheduled)}
+			}
+		}
+	}()
+
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		corrected.Add(r.corrected)
+		recordResult(r.corrected, r.method, r.url)
+		if out.encoder != nil {
+			out.encoder.Encode(r.corrected)
+		}
+		if r.raw != nil {
+			raw.Add(r.raw)
+		}
+	}
+	corrected.Close()
+	raw.Close()
+	return corrected, raw
+}
+
+// fire sends a single request and returns both its corrected result
+// (latency from the scheduled dispatch time) and its raw one (latency
+// from the actual send time), each paired with the method/URL of the
+// target that was dispatched.
+func fire(client *http.Client, targeter vegeta.Targeter, scheduled time.Time) comResult {
+	var tgt vegeta.Target
+	err := targeter(&tgt)
+	send := time.Now()
+	if err != nil {
+		return comResult{corrected: errResult(scheduled, err), raw: errResult(send, err), method: tgt.Method, url: tgt.URL}
+	}
+
+	req, err := tgt.Request()
+	if err != nil {
+		return comResult{corrected: errResult(scheduled, err), raw: errResult(send, err), method: tgt.Method, url: tgt.URL}
+	}
+
+	resp, err := client.Do(req)
+	finish := time.Now()
+	if err != nil {
+		return comResult{corrected: errResult(scheduled, err), raw: errResult(send, err), method: tgt.Method, url: tgt.URL}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	corrected := &vegeta.Result{
+		Code:      uint16(resp.StatusCode),
+		Timestamp: scheduled,
+		Latency:   finish.Sub(scheduled),
+		BytesIn:   uint64(len(body)),
+	}
+	raw := &vegeta.Result{
+		Code:      uint16(resp.StatusCode),
+		Timestamp: send,
+		Latency:   finish.Sub(send),
+		BytesIn:   uint64(len(body)),
+	}
+	return comResult{corrected: corrected, raw: raw, method: tgt.Method, url: tgt.URL}
+}
+
+// lateResult records a request that missed its dispatch schedule
+// entirely because every worker was still busy.
+func lateResult(scheduled time.Time) *vegeta.Result {
+	return &vegeta.Result{
+		Timestamp: scheduled,
+		Latency:   time.Since(scheduled),
+		Error:     "late: no worker free at scheduled dispatch time",
+	}
+}
+
+// errResult records a request that failed before a response was read.
+func errResult(at time.Time, err error) *vegeta.Result {
+	return &vegeta.Result{
+		Timestamp: at,
+		Latency:   time.Since(at),
+		Error:     err.Error(),
+	}
+}
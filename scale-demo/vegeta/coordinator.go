@@ -0,0 +1,256 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/lib"
+)
+
+// -mode=coordinator partitions the aggregate -rate across the worker
+// pods that register on -control-addr within -register-wait, then merges
+// the vegeta.Result batches they stream back into the usual HTTPReporter,
+// served as always on -address. -mode=worker dials -coordinator, runs the
+// rate share it's handed, and streams its results back. This is the way
+// to drive more load than one pod's NIC/CPU can sustain. Each -rates step
+// is a separate round: the coordinator listens again and waits
+// -register-wait for workers, while a worker runs a single assignment and
+// exits, so every step only reaches workers that register anew. Workers
+// always use vegeta's closed-model attacker; -max-workers open-model mode
+// isn't distributed.
+var (
+	mode         = flag.String("mode", "standalone", "'standalone' (default), 'coordinator', or 'worker'")
+	controlAddr  = flag.String("control-addr", ":7070", "In -mode=coordinator, the TCP control-plane address to listen on for worker registration")
+	coordinator  = flag.String("coordinator", "", "In -mode=worker, the coordinator's -control-addr to register with")
+	registerWait = flag.Duration("register-wait", 10*time.Second, "In -mode=coordinator, how long to wait for workers to register before partitioning -rate and starting the attack")
+)
+
+// registerMsg is sent by a worker immediately after connecting.
+type registerMsg struct {
+	ID string
+}
+
+// assignMsg is the coordinator's reply: the share of the attack this
+// worker should drive.
+type assignMsg struct {
+	Targets  []vegeta.Target
+	Rate     int
+	Duration time.Duration
+}
+
+// resultBatch streams a worker's results back to the coordinator, paired
+// with the method and URL of each request: vegeta.Result carries
+// neither, and the worker is the only place that still has the
+// vegeta.Target each result came from. Done, once true, signals the
+// worker has nothing further to send.
+type resultBatch struct {
+	Results []*vegeta.Result
+	Methods []string
+	URLs    []string
+	Done    bool
+}
+
+// writeFrame writes v as a length-prefixed gob message.
+func writeFrame(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(buf.Len())); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readFrame reads one length-prefixed gob message written by writeFrame.
+func readFrame(r io.Reader, v interface{}) error {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(buf)).Decode(v)
+}
+
+// partitionRate splits rate across n workers as evenly as possible,
+// handing any remainder to the first workers so the shares sum back to
+// exactly rate. Every worker gets at least 1: a share of 0 means
+// "unlimited" to vegeta.Attacker, not "idle", so silently truncating to
+// 0 would make workers flood the target instead of throttling it.
+func partitionRate(rate, n int) ([]int, error) {
+	if rate < n {
+		return nil, fmt.Errorf("-rate/-rates step %d is lower than the %d registered workers: "+
+			"each worker needs at least 1 req/s, which would exceed the requested aggregate rate", rate, n)
+	}
+
+	shares := make([]int, n)
+	base, remainder := rate/n, rate%n
+	for i := range shares {
+		shares[i] = base
+		if i < remainder {
+			shares[i]++
+		}
+	}
+	return shares, nil
+}
+
+// runCoordinator listens on *controlAddr for workers, partitions s.rate
+// across however many register within *registerWait, hands each its
+// share, and merges the streamed results into a single vegeta.Metrics.
+func runCoordinator(s step, reporter *HTTPReporter, targets []vegeta.Target) error {
+	ln, err := net.Listen("tcp", *controlAddr)
+	if err != nil {
+		return fmt.Errorf("listening on -control-addr %s: %v", *controlAddr, err)
+	}
+	defer ln.Close()
+
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var reg registerMsg
+			if err := readFrame(conn, &reg); err != nil {
+				conn.Close()
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "worker %s registered from %s\n", reg.ID, conn.RemoteAddr())
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+
+	time.Sleep(*registerWait)
+
+	mu.Lock()
+	workers := append([]net.Conn(nil), conns...)
+	mu.Unlock()
+	if len(workers) == 0 {
+		return fmt.Errorf("no workers registered within -register-wait=%s", *registerWait)
+	}
+
+	shares, err := partitionRate(s.rate, len(workers))
+	if err != nil {
+		return err
+	}
+
+	metrics := &vegeta.Metrics{}
+	var wg sync.WaitGroup
+	for i, conn := range workers {
+		assignment := assignMsg{Targets: targets, Rate: shares[i], Duration: s.duration}
+		wg.Add(1)
+		go func(conn net.Conn, assignment assignMsg) {
+			defer wg.Done()
+			defer conn.Close()
+			if err := writeFrame(conn, assignment); err != nil {
+				fmt.Fprintf(os.Stderr, "sending assignment to %s: %v\n", conn.RemoteAddr(), err)
+				return
+			}
+			for {
+				var batch resultBatch
+				if err := readFrame(conn, &batch); err != nil {
+					return
+				}
+				mu.Lock()
+				for i, res := range batch.Results {
+					metrics.Add(res)
+					recordResult(res, batch.Methods[i], batch.URLs[i])
+				}
+				mu.Unlock()
+				if batch.Done {
+					return
+				}
+			}
+		}(conn, assignment)
+	}
+	wg.Wait()
+
+	metrics.Close()
+	reporter.SetMetrics(metrics)
+	reporter.SetStepMetrics(s.rate, metrics)
+	return nil
+}
+
+// runWorker dials *coordinator, registers, runs the rate share it's
+// assigned, and streams results back as they come in.
+func runWorker(attacker *vegeta.Attacker, stop chan os.Signal) error {
+	conn, err := net.Dial("tcp", *coordinator)
+	if err != nil {
+		return fmt.Errorf("dialing -coordinator %s: %v", *coordinator, err)
+	}
+	defer conn.Close()
+
+	hostname, _ := os.Hostname()
+	if err := writeFrame(conn, registerMsg{ID: hostname}); err != nil {
+		return fmt.Errorf("registering with coordinator: %v", err)
+	}
+
+	var assignment assignMsg
+	if err := readFrame(conn, &assignment); err != nil {
+		return fmt.Errorf("reading assignment from coordinator: %v", err)
+	}
+
+	targeter := vegeta.NewStaticTargeter(assignment.Targets...)
+	wrapped, labels := labeledTargeter(targeter)
+
+	const batchSize = 100
+	var batch resultBatch
+	flush := func(done bool) error {
+		if len(batch.Results) == 0 && !done {
+			return nil
+		}
+		batch.Done = done
+		err := writeFrame(conn, batch)
+		batch = resultBatch{}
+		return err
+	}
+
+	pacer := vegeta.Rate{Freq: assignment.Rate, Per: time.Second}
+	for res := range attacker.Attack(wrapped, pacer, assignment.Duration, "worker") {
+		method, url := labels()
+		batch.Results = append(batch.Results, res)
+		batch.Methods = append(batch.Methods, method)
+		batch.URLs = append(batch.URLs, url)
+		if len(batch.Results) >= batchSize {
+			if err := flush(false); err != nil {
+				return fmt.Errorf("streaming results to coordinator: %v", err)
+			}
+		}
+		if len(stop) > 0 {
+			break
+		}
+	}
+	return flush(true)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestWriteReadFrame(t *testing.T) {
+	want := assignMsg{Rate: 42, Duration: 0}
+
+	var buf bytes.Buffer
+	if err := writeFrame(&buf, want); err != nil {
+		t.Fatalf("writeFrame() error = %v", err)
+	}
+
+	var got assignMsg
+	if err := readFrame(&buf, &got); err != nil {
+		t.Fatalf("readFrame() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readFrame() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteReadFrameMultiple(t *testing.T) {
+	var buf bytes.Buffer
+	want := []resultBatch{
+		{Done: false},
+		{Done: true},
+	}
+	for _, m := range want {
+		if err := writeFrame(&buf, m); err != nil {
+			t.Fatalf("writeFrame() error = %v", err)
+		}
+	}
+
+	for _, w := range want {
+		var got resultBatch
+		if err := readFrame(&buf, &got); err != nil {
+			t.Fatalf("readFrame() error = %v", err)
+		}
+		if got.Done != w.Done {
+			t.Errorf("readFrame() = %+v, want %+v", got, w)
+		}
+	}
+}
+
+func TestPartitionRate(t *testing.T) {
+	shares, err := partitionRate(10, 3)
+	if err != nil {
+		t.Fatalf("partitionRate() error = %v", err)
+	}
+
+	sum := 0
+	for _, s := range shares {
+		if s < 1 {
+			t.Errorf("partitionRate() share %d is below 1", s)
+		}
+		sum += s
+	}
+	if sum != 10 {
+		t.Errorf("partitionRate() shares sum to %d, want 10", sum)
+	}
+}
+
+func TestPartitionRateTooFewForWorkers(t *testing.T) {
+	if _, err := partitionRate(2, 5); err == nil {
+		t.Error("partitionRate(2, 5) with rate below worker count, want error")
+	}
+}
@@ -25,53 +25,25 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
-
-	vegeta "github.com/tsenart/vegeta/lib"
 )
 
 var (
-	host       = flag.String("host", "", "The host to load test")
-	port       = flag.Int("port", 80, "The port to load test")
-	paths      = flag.String("paths", "/", "A comma separated list of URL paths to load test")
-	rate       = flag.Int("rate", 0, "The QPS to send")
-	resultsDir = flag.String("results", "", "If set, a directory in which to save results")
-	duration   = flag.Duration("duration", 10*time.Second, "The duration of the load test")
-	addr       = flag.String("address", "localhost:8080", "The address to serve on")
-	workers    = flag.Int("workers", 10, "The number of workers to use")
+	host         = flag.String("host", "", "The host to load test")
+	port         = flag.Int("port", 80, "The port to load test")
+	paths        = flag.String("paths", "/", "A comma separated list of URL paths to load test")
+	rate         = flag.Int("rate", 0, "The QPS to send")
+	rates        = flag.String("rates", "", "A comma separated list of QPS values to ramp through, e.g. 100,200,400,800. Overrides -rate")
+	stepDuration = flag.Duration("step-duration", 10*time.Second, "The duration of each rate in -rates")
+	resultsDir   = flag.String("results", "", "If set, a directory in which to save results")
+	duration     = flag.Duration("duration", 10*time.Second, "The duration of the load test")
+	addr         = flag.String("address", "localhost:8080", "The address to serve on")
+	workers      = flag.Int("workers", 10, "The number of workers to use")
 )
 
-// HTTPReporter outputs metrics over HTTP
-type HTTPReporter struct {
-	sync.Mutex
-	metrics *vegeta.Metrics
-}
-
-func (h *HTTPReporter) ServeHTTP(res http.ResponseWriter, req *http.Request) {
-	metrics := h.GetMetrics()
-
-	res.WriteHeader(http.StatusOK)
-	reporter := vegeta.NewJSONReporter(metrics)
-	reporter.Report(res)
-}
-
-// GetMetrics returns the current metrics for this reporter
-func (h *HTTPReporter) GetMetrics() *vegeta.Metrics {
-	h.Lock()
-	defer h.Unlock()
-	return h.metrics
-}
-
-// SetMetrics sets the current metrics for this reporter
-func (h *HTTPReporter) SetMetrics(metrics *vegeta.Metrics) {
-	h.Lock()
-	defer h.Unlock()
-	h.metrics = metrics
-}
-
 type output struct {
 	encoder *json.Encoder
 	file    *os.File
@@ -85,10 +57,10 @@ func (o *output) close() {
 	}
 }
 
-func (o *output) rotate() error {
+func (o *output) rotate(resultsDir string, rate int) error {
 	o.close()
 
-	o.name = path.Join(*resultsDir, fmt.Sprintf("results-%d.json", time.Now().Unix()))
+	o.name = path.Join(resultsDir, fmt.Sprintf("results-%d-%d.json", rate, time.Now().Unix()))
 	file, err := os.Create(o.name + ".tmp")
 	if err != nil {
 		return err
@@ -98,9 +70,43 @@ func (o *output) rotate() error {
 	return nil
 }
 
+// steps parses the -rates/-step-duration flags into a load profile. When
+// -rates isn't set, it falls back to the single -rate/-duration pair, so a
+// stepped profile is purely additive over the original fixed-rate mode.
+func steps() ([]step, error) {
+	if *rates == "" {
+		return []step{{rate: *rate, duration: *duration}}, nil
+	}
+
+	var out []step
+	for _, r := range strings.Split(*rates, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(r))
+		if err != nil {
+			return nil, fmt.Errorf("invalid -rates value %q: %v", r, err)
+		}
+		out = append(out, step{rate: n, duration: *stepDuration})
+	}
+	return out, nil
+}
+
 func main() {
 	flag.Parse()
 
+	if *mode == "worker" {
+		attacker, err := buildAttacker()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		if err := runWorker(attacker, stop); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
 	var serviceIP string
 	ips, err := net.LookupIP(*host)
 	if err != nil {
@@ -120,48 +126,92 @@ func main() {
 	}
 
 	headers := http.Header{"Host": []string{*host}}
-	host := serviceIP
+	hostPort := serviceIP
 	if *port != 80 {
-		host = fmt.Sprintf("%s:%d", host, *port)
+		hostPort = fmt.Sprintf("%s:%d", hostPort, *port)
+	}
+
+	loadSteps, err := steps()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err := initPrometheus(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	var targets []vegeta.Target
-	for _, path := range strings.Split(*paths, ",") {
-		path = strings.TrimPrefix(path, "/")
-		targets = append(targets, vegeta.Target{
-			Method: "GET",
-			URL:    fmt.Sprintf("http://%s/%s", host, path),
-			Header: headers,
-		})
+
+	if *mode == "coordinator" {
+		if *targetsFile != "" {
+			fmt.Fprintln(os.Stderr, "-targets is not yet supported with -mode=coordinator: "+
+				"workers only ever receive the static -paths GET list, so refusing to silently "+
+				"drop the -targets file instead of attacking what it describes")
+			os.Exit(2)
+		}
+
+		reporter := newHTTPReporter()
+		go http.ListenAndServe(*addr, reporter)
+
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+		targets := staticTargets(hostPort, headers)
+		for len(stop) == 0 {
+			for _, s := range loadSteps {
+				if len(stop) > 0 {
+					break
+				}
+				if err := runCoordinator(s, reporter, targets); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(2)
+				}
+			}
+		}
+		return
 	}
-	targeter := vegeta.NewStaticTargeter(targets...)
-	attacker := vegeta.NewAttacker(vegeta.Workers(uint64(*workers)))
 
-	reporter := &HTTPReporter{}
+	targeter, err := buildTargeter(hostPort, headers)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	attacker, err := buildAttacker()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var client *http.Client
+	if *maxWorkers > 0 {
+		client, err = buildHTTPClient()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	reporter := newHTTPReporter()
 	go http.ListenAndServe(*addr, reporter)
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
-	out := &output{}
-	defer out.close()
 	for len(stop) == 0 {
-		metrics := &vegeta.Metrics{}
-		if *resultsDir != "" {
-			if err := out.rotate(); err != nil {
-				fmt.Fprintln(os.Stderr, "Error opening results file:", err)
-				os.Exit(3)
-			}
-		}
-		for res := range attacker.Attack(targeter, uint64(*rate), *duration) {
-			metrics.Add(res)
-			if out.encoder != nil {
-				out.encoder.Encode(res)
-			}
+		for _, s := range loadSteps {
 			if len(stop) > 0 {
 				break
 			}
+			if *maxWorkers > 0 {
+				corrected, raw := runOpenModelStep(client, targeter, s, *resultsDir, stop)
+				reporter.SetStepMetrics(s.rate, corrected)
+				reporter.SetMetrics(corrected)
+				reporter.SetRawMetrics(raw)
+				continue
+			}
+			metrics := runStep(attacker, targeter, s, *resultsDir, stop)
+			reporter.SetStepMetrics(s.rate, metrics)
+			reporter.SetMetrics(metrics)
 		}
-		metrics.Close()
-		reporter.SetMetrics(metrics)
 	}
 }
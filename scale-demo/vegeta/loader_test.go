@@ -0,0 +1,66 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStepsSingleRate(t *testing.T) {
+	*rates = ""
+	*rate = 50
+	*duration = 5 * time.Second
+
+	got, err := steps()
+	if err != nil {
+		t.Fatalf("steps() error = %v", err)
+	}
+	want := []step{{rate: 50, duration: 5 * time.Second}}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Errorf("steps() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStepsRamp(t *testing.T) {
+	*rates = "100, 200,400"
+	*stepDuration = 2 * time.Second
+	defer func() { *rates = "" }()
+
+	got, err := steps()
+	if err != nil {
+		t.Fatalf("steps() error = %v", err)
+	}
+	wantRates := []int{100, 200, 400}
+	if len(got) != len(wantRates) {
+		t.Fatalf("steps() returned %d steps, want %d", len(got), len(wantRates))
+	}
+	for i, s := range got {
+		if s.rate != wantRates[i] || s.duration != 2*time.Second {
+			t.Errorf("steps()[%d] = %+v, want rate %d duration %s", i, s, wantRates[i], 2*time.Second)
+		}
+	}
+}
+
+func TestStepsInvalidRate(t *testing.T) {
+	*rates = "100,oops"
+	defer func() { *rates = "" }()
+
+	if _, err := steps(); err == nil {
+		t.Error("steps() with a non-numeric rate, want error")
+	}
+}
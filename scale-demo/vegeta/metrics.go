@@ -0,0 +1,122 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	vegeta "github.com/tsenart/vegeta/lib"
+)
+
+var latencyBuckets = flag.String("latency-buckets", "0.001,0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10",
+	"A comma separated list of request latency histogram buckets, in seconds")
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vegeta_requests_total",
+		Help: "Total number of requests sent, by target path and method.",
+	}, []string{"path", "method"})
+
+	responsesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vegeta_responses_total",
+		Help: "Total number of responses received, by target path, method and status code.",
+	}, []string{"path", "method", "code"})
+
+	errorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vegeta_errors_total",
+		Help: "Total number of requests that errored out, by target path and method.",
+	}, []string{"path", "method"})
+
+	bytesIn = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vegeta_bytes_in_total",
+		Help: "Total bytes read from responses, by target path and method.",
+	}, []string{"path", "method"})
+
+	bytesOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vegeta_bytes_out_total",
+		Help: "Total bytes written in requests, by target path and method.",
+	}, []string{"path", "method"})
+
+	requestLatency *prometheus.HistogramVec
+)
+
+// initPrometheus parses -latency-buckets and registers the collectors
+// above with the default registry. It must be called once, after flags
+// are parsed, before the first result is recorded.
+func initPrometheus() error {
+	buckets, err := parseBuckets(*latencyBuckets)
+	if err != nil {
+		return err
+	}
+
+	requestLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vegeta_request_latency_seconds",
+		Help:    "Request latency in seconds, by target path and method.",
+		Buckets: buckets,
+	}, []string{"path", "method"})
+
+	prometheus.MustRegister(requestsTotal, responsesTotal, errorsTotal, bytesIn, bytesOut, requestLatency)
+	return nil
+}
+
+func parseBuckets(s string) ([]float64, error) {
+	var out []float64
+	for _, b := range strings.Split(s, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -latency-buckets value %q: %v", b, err)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// recordResult feeds a single vegeta.Result into the Prometheus
+// collectors so that `/metrics` stays current as an attack progresses.
+// method and url identify the request the result came from: vegeta.Result
+// carries neither, so callers must capture them off the vegeta.Target at
+// dispatch time and pass them in explicitly.
+func recordResult(res *vegeta.Result, method, url string) {
+	path := requestPath(url)
+	if method == "" {
+		method = "GET"
+	}
+
+	requestsTotal.WithLabelValues(path, method).Inc()
+	responsesTotal.WithLabelValues(path, method, strconv.Itoa(int(res.Code))).Inc()
+	bytesIn.WithLabelValues(path, method).Add(float64(res.BytesIn))
+	bytesOut.WithLabelValues(path, method).Add(float64(res.BytesOut))
+	requestLatency.WithLabelValues(path, method).Observe(res.Latency.Seconds())
+	if res.Error != "" {
+		errorsTotal.WithLabelValues(path, method).Inc()
+	}
+}
+
+// requestPath extracts the URL path to use as a metric label, falling
+// back to the raw string if it doesn't parse as a URL.
+func requestPath(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Path == "" {
+		return rawurl
+	}
+	return u.Path
+}
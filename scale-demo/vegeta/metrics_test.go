@@ -0,0 +1,53 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "testing"
+
+func TestParseBuckets(t *testing.T) {
+	got, err := parseBuckets("0.01, 0.1,1")
+	if err != nil {
+		t.Fatalf("parseBuckets() error = %v", err)
+	}
+	want := []float64{0.01, 0.1, 1}
+	if len(got) != len(want) {
+		t.Fatalf("parseBuckets() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseBuckets()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseBucketsInvalid(t *testing.T) {
+	if _, err := parseBuckets("0.01,nope"); err == nil {
+		t.Error("parseBuckets() with a non-numeric bucket, want error")
+	}
+}
+
+func TestRequestPath(t *testing.T) {
+	cases := map[string]string{
+		"http://10.0.0.1:8080/foo/bar": "/foo/bar",
+		"%zz":                          "%zz",
+	}
+	for in, want := range cases {
+		if got := requestPath(in); got != want {
+			t.Errorf("requestPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	vegeta "github.com/tsenart/vegeta/lib"
+)
+
+// HTTPReporter outputs metrics over HTTP. It serves the metrics for the
+// most recently completed attack at "/", and, for a stepped/ramp attack,
+// the metrics for each individual rate step at "/steps/<rate>". In
+// -max-workers open-model mode, the coordinated-omission-corrected
+// metrics are served at "/" as usual, with the raw, uncorrected ones
+// alongside at "/raw".
+type HTTPReporter struct {
+	sync.Mutex
+	metrics     *vegeta.Metrics
+	rawMetrics  *vegeta.Metrics
+	stepMetrics map[int]*vegeta.Metrics
+}
+
+// newHTTPReporter returns an HTTPReporter ready to serve metrics.
+func newHTTPReporter() *HTTPReporter {
+	return &HTTPReporter{stepMetrics: map[int]*vegeta.Metrics{}}
+}
+
+func (h *HTTPReporter) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/metrics" {
+		promhttp.Handler().ServeHTTP(res, req)
+		return
+	}
+	if strings.HasPrefix(req.URL.Path, "/steps/") {
+		h.serveStep(res, strings.TrimPrefix(req.URL.Path, "/steps/"))
+		return
+	}
+	if req.URL.Path == "/raw" {
+		writeMetrics(res, h.GetRawMetrics())
+		return
+	}
+	writeMetrics(res, h.GetMetrics())
+}
+
+func (h *HTTPReporter) serveStep(res http.ResponseWriter, rateParam string) {
+	rate, err := strconv.Atoi(rateParam)
+	if err != nil {
+		http.Error(res, fmt.Sprintf("invalid rate %q: %v", rateParam, err), http.StatusBadRequest)
+		return
+	}
+
+	metrics := h.GetStepMetrics(rate)
+	if metrics == nil {
+		http.Error(res, fmt.Sprintf("no results yet for rate %d", rate), http.StatusNotFound)
+		return
+	}
+	writeMetrics(res, metrics)
+}
+
+func writeMetrics(res http.ResponseWriter, metrics *vegeta.Metrics) {
+	res.WriteHeader(http.StatusOK)
+	reporter := vegeta.NewJSONReporter(metrics)
+	reporter.Report(res)
+}
+
+// GetMetrics returns the current metrics for this reporter
+func (h *HTTPReporter) GetMetrics() *vegeta.Metrics {
+	h.Lock()
+	defer h.Unlock()
+	return h.metrics
+}
+
+// SetMetrics sets the current metrics for this reporter
+func (h *HTTPReporter) SetMetrics(metrics *vegeta.Metrics) {
+	h.Lock()
+	defer h.Unlock()
+	h.metrics = metrics
+}
+
+// GetRawMetrics returns the uncorrected metrics from the most recent
+// -max-workers open-model attack, or nil outside that mode.
+func (h *HTTPReporter) GetRawMetrics() *vegeta.Metrics {
+	h.Lock()
+	defer h.Unlock()
+	return h.rawMetrics
+}
+
+// SetRawMetrics sets the uncorrected metrics for the most recent
+// -max-workers open-model attack.
+func (h *HTTPReporter) SetRawMetrics(metrics *vegeta.Metrics) {
+	h.Lock()
+	defer h.Unlock()
+	h.rawMetrics = metrics
+}
+
+// GetStepMetrics returns the metrics recorded for the given rate step, or
+// nil if that step hasn't completed yet.
+func (h *HTTPReporter) GetStepMetrics(rate int) *vegeta.Metrics {
+	h.Lock()
+	defer h.Unlock()
+	return h.stepMetrics[rate]
+}
+
+// SetStepMetrics records the metrics for a completed rate step.
+func (h *HTTPReporter) SetStepMetrics(rate int, metrics *vegeta.Metrics) {
+	h.Lock()
+	defer h.Unlock()
+	h.stepMetrics[rate] = metrics
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2015 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	vegeta "github.com/tsenart/vegeta/lib"
+)
+
+var (
+	targetsFile = flag.String("targets", "", "A vegeta targets file (see -format). Overrides -paths")
+	format      = flag.String("format", "http", "The format of -targets: 'http' or 'json'")
+	scheme      = flag.String("scheme", "http", "The URL scheme to use for -paths targets: 'http' or 'https'")
+)
+
+// buildTargeter returns the Targeter the attack should use: one parsed
+// from -targets if set, falling back to the static GET-per-path list
+// built from -host/-port/-paths otherwise. headers is merged into every
+// target, which is how the Kubernetes Service Host header keeps getting
+// set even for rich, user-supplied targets.
+func buildTargeter(hostPort string, headers http.Header) (vegeta.Targeter, error) {
+	if *targetsFile == "" {
+		return staticTargeter(hostPort, headers), nil
+	}
+
+	// NewHTTPTargeter only wraps its io.Reader in a lazy scanner that
+	// reads on each Targeter call, long after this function returns, so
+	// the source can't be a file we close on the way out. Read it into
+	// memory instead; NewJSONTargeter drains its reader eagerly anyway,
+	// but reading it the same way keeps both branches equally safe.
+	contents, err := os.ReadFile(*targetsFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading -targets file: %v", err)
+	}
+
+	switch *format {
+	case "http":
+		return vegeta.NewHTTPTargeter(bytes.NewReader(contents), nil, headers), nil
+	case "json":
+		return vegeta.NewJSONTargeter(bytes.NewReader(contents), nil, headers), nil
+	default:
+		return nil, fmt.Errorf("unknown -format %q, want 'http' or 'json'", *format)
+	}
+}
+
+// staticTargeter builds the original fixed list of GET targets from
+// -paths, one per comma separated entry.
+func staticTargeter(hostPort string, headers http.Header) vegeta.Targeter {
+	return vegeta.NewStaticTargeter(staticTargets(hostPort, headers)...)
+}
+
+// staticTargets is the []vegeta.Target backing staticTargeter. It's
+// exposed separately so -mode=coordinator can ship the concrete target
+// list to workers over the wire, which a lazily-parsed -targets file
+// targeter can't do.
+func staticTargets(hostPort string, headers http.Header) []vegeta.Target {
+	var targets []vegeta.Target
+	for _, p := range strings.Split(*paths, ",") {
+		p = strings.TrimPrefix(p, "/")
+		targets = append(targets, vegeta.Target{
+			Method: "GET",
+			URL:    fmt.Sprintf("%s://%s/%s", *scheme, hostPort, p),
+			Header: headers,
+		})
+	}
+	return targets
+}